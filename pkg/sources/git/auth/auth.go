@@ -0,0 +1,203 @@
+// Package auth provides pluggable credential resolution for cloning and
+// syncing git repositories across SSH and HTTPS, so a single mercator
+// process can pull from multiple forges with different credentials in one
+// reconcile loop.
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Auth resolves the transport.AuthMethod go-git should use to talk to a
+// given repository URL.
+type Auth interface {
+	Method(repoUrl string) (transport.AuthMethod, error)
+}
+
+// SSHKey authenticates with an in-memory private key, optionally protected
+// by a password. This is the historical CloneOptions.PemBytes/PemPassword
+// behavior, moved behind the Auth interface.
+type SSHKey struct {
+	Bytes    []byte
+	Password string
+}
+
+func (a SSHKey) Method(repoUrl string) (transport.AuthMethod, error) {
+	return gitssh.NewPublicKeys("git", a.Bytes, a.Password)
+}
+
+// SSHAgent authenticates through a running ssh-agent, reached over the
+// socket named by SSH_AUTH_SOCK.
+type SSHAgent struct{}
+
+func (a SSHAgent) Method(repoUrl string) (transport.AuthMethod, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("auth: SSH_AUTH_SOCK is not set, cannot reach ssh-agent")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("auth: dialing ssh-agent: %w", err)
+	}
+
+	agentClient := agent.NewClient(conn)
+	return &gitssh.PublicKeysCallback{User: "git", Callback: agentClient.Signers}, nil
+}
+
+// HTTPToken authenticates HTTPS remotes with a personal access token, as
+// issued by GitHub, Gitea, or GitLab.
+type HTTPToken struct {
+	Username string
+	Token    string
+}
+
+func (a HTTPToken) Method(repoUrl string) (transport.AuthMethod, error) {
+	return &githttp.BasicAuth{Username: a.Username, Password: a.Token}, nil
+}
+
+// NetrcAuth authenticates HTTPS remotes by looking up the repository's host
+// in the user's ~/.netrc (or $NETRC, if set).
+type NetrcAuth struct{}
+
+func (a NetrcAuth) Method(repoUrl string) (transport.AuthMethod, error) {
+	host, err := hostOf(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	username, password, err := lookupNetrc(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &githttp.BasicAuth{Username: username, Password: password}, nil
+}
+
+func lookupNetrc(host string) (string, string, error) {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		path = home + "/.netrc"
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("auth: reading netrc: %w", err)
+	}
+
+	fields := strings.Fields(string(contents))
+
+	var machine, login, password string
+	matched := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			i++
+			if i >= len(fields) {
+				continue
+			}
+			machine = fields[i]
+			matched = machine == host
+		case "login":
+			i++
+			if matched && i < len(fields) {
+				login = fields[i]
+			}
+		case "password":
+			i++
+			if matched && i < len(fields) {
+				password = fields[i]
+			}
+		}
+	}
+
+	if login == "" {
+		return "", "", fmt.Errorf("auth: no netrc entry for host %q", host)
+	}
+
+	return login, password, nil
+}
+
+func hostOf(repoUrl string) (string, error) {
+	if !strings.Contains(repoUrl, "://") {
+		// scp-like syntax, e.g. git@github.com:zablvit/mercator.git
+		if at := strings.Index(repoUrl, "@"); at >= 0 {
+			rest := repoUrl[at+1:]
+			if colon := strings.Index(rest, ":"); colon >= 0 {
+				return rest[:colon], nil
+			}
+		}
+		return "", fmt.Errorf("auth: cannot determine host from url %q", repoUrl)
+	}
+
+	u, err := url.Parse(repoUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Hostname(), nil
+}
+
+// CredentialStore resolves an Auth by the host of the repository being
+// cloned or synced, so one mercator process can authenticate against
+// multiple forges with different credentials.
+type CredentialStore struct {
+	mu       sync.RWMutex
+	byHost   map[string]Auth
+	fallback Auth
+}
+
+// NewCredentialStore returns an empty CredentialStore.
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{byHost: make(map[string]Auth)}
+}
+
+// Register associates an Auth with a host, e.g. "github.com".
+func (s *CredentialStore) Register(host string, a Auth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byHost[host] = a
+}
+
+// SetFallback sets the Auth to use for hosts with no registered credentials.
+func (s *CredentialStore) SetFallback(a Auth) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = a
+}
+
+// Resolve returns the Auth registered for the host of repoUrl, falling back
+// to the store's fallback Auth if one was set.
+func (s *CredentialStore) Resolve(repoUrl string) (Auth, error) {
+	host, err := hostOf(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if a, ok := s.byHost[host]; ok {
+		return a, nil
+	}
+
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+
+	return nil, fmt.Errorf("auth: no credentials registered for host %q", host)
+}
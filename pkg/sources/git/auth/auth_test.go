@@ -0,0 +1,42 @@
+package auth_test
+
+import (
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zablvit/mercator/pkg/sources/git/auth"
+)
+
+func TestShouldResolveCredentialsByHost(t *testing.T) {
+	store := auth.NewCredentialStore()
+	store.Register("github.com", auth.HTTPToken{Username: "github-user", Token: "github-token"})
+	store.Register("gitlab.com", auth.HTTPToken{Username: "gitlab-user", Token: "gitlab-token"})
+
+	resolved, err := store.Resolve("https://github.com/zablvit/mercator")
+	require.NoError(t, err)
+
+	method, err := resolved.Method("https://github.com/zablvit/mercator")
+	require.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "github-user", Password: "github-token"}, method)
+}
+
+func TestShouldFailResolvingCredentialsForUnregisteredHostWithoutFallback(t *testing.T) {
+	store := auth.NewCredentialStore()
+
+	_, err := store.Resolve("https://bitbucket.org/zablvit/mercator")
+	require.Error(t, err)
+}
+
+func TestShouldFallBackToDefaultCredentials(t *testing.T) {
+	store := auth.NewCredentialStore()
+	store.SetFallback(auth.HTTPToken{Username: "default-user", Token: "default-token"})
+
+	resolved, err := store.Resolve("https://bitbucket.org/zablvit/mercator")
+	require.NoError(t, err)
+
+	method, err := resolved.Method("https://bitbucket.org/zablvit/mercator")
+	require.NoError(t, err)
+	assert.Equal(t, &githttp.BasicAuth{Username: "default-user", Password: "default-token"}, method)
+}
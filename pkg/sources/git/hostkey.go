@@ -0,0 +1,174 @@
+package git
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy controls how Clone and Sync verify the SSH host key
+// presented by the remote.
+type HostKeyPolicy int
+
+const (
+	// StrictKnownHosts verifies the host key against KnownHosts or
+	// KnownHostsPath and refuses any host not found there. This is the
+	// default (zero value) policy.
+	StrictKnownHosts HostKeyPolicy = iota
+
+	// TOFU trusts the first host key it sees for a given host and persists
+	// it to TOFUKnownHostsWriter for future verification.
+	TOFU
+
+	// InsecureIgnoreHostKey accepts any host key without verification. It
+	// exists for tests and must not be used against untrusted networks.
+	InsecureIgnoreHostKey
+)
+
+// applyHostKeyPolicy wires options' HostKeyPolicy into the HostKeyCallback
+// of an SSH auth method, in place of the SSH_KNOWN_HOSTS environment
+// variable mercator relied on previously.
+func applyHostKeyPolicy(method transport.AuthMethod, options CloneOptions) error {
+	switch m := method.(type) {
+	case *gitssh.PublicKeys:
+		callback, err := hostKeyCallback(options)
+		if err != nil {
+			return err
+		}
+		m.HostKeyCallback = callback
+	case *gitssh.PublicKeysCallback:
+		callback, err := hostKeyCallback(options)
+		if err != nil {
+			return err
+		}
+		m.HostKeyCallback = callback
+	}
+
+	return nil
+}
+
+func hostKeyCallback(options CloneOptions) (gossh.HostKeyCallback, error) {
+	pinned, err := parsePinnedHostKeys(options.PinnedHostKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	fallback, err := baseHostKeyCallback(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		if want, ok := pinned[knownhosts.Normalize(hostname)]; ok {
+			if !bytes.Equal(want.Marshal(), key.Marshal()) {
+				return fmt.Errorf("git: host key for %q does not match pinned key", hostname)
+			}
+			return nil
+		}
+
+		return fallback(hostname, remote, key)
+	}, nil
+}
+
+func baseHostKeyCallback(options CloneOptions) (gossh.HostKeyCallback, error) {
+	switch options.HostKeyPolicy {
+	case TOFU:
+		return tofuHostKeyCallback(options.TOFUKnownHostsWriter), nil
+	case InsecureIgnoreHostKey:
+		return gossh.InsecureIgnoreHostKey(), nil
+	default:
+		return strictHostKeyCallback(options)
+	}
+}
+
+func strictHostKeyCallback(options CloneOptions) (gossh.HostKeyCallback, error) {
+	if len(options.KnownHosts) > 0 {
+		tmp, err := os.CreateTemp("", "mercator-known-hosts-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+
+		if _, err := tmp.Write(options.KnownHosts); err != nil {
+			return nil, err
+		}
+		if err := tmp.Close(); err != nil {
+			return nil, err
+		}
+
+		return knownhosts.New(tmp.Name())
+	}
+
+	if options.KnownHostsPath != "" {
+		return knownhosts.New(options.KnownHostsPath)
+	}
+
+	// With only PinnedHostKeys configured, trust the pinned hosts and refuse
+	// every other host.
+	if len(options.PinnedHostKeys) > 0 {
+		return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+			return fmt.Errorf("git: host key for %q is not pinned and no known_hosts is configured", hostname)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("git: StrictKnownHosts requires KnownHosts or KnownHostsPath to be set")
+}
+
+// tofuTrustStore holds the host keys TOFU has trusted so far in this
+// process, so a host seen again is verified against the key it was first
+// trusted with instead of being accepted unconditionally.
+var tofuTrustStore = struct {
+	mu   sync.Mutex
+	keys map[string]gossh.PublicKey
+}{keys: make(map[string]gossh.PublicKey)}
+
+func tofuHostKeyCallback(w io.Writer) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		host := knownhosts.Normalize(hostname)
+
+		tofuTrustStore.mu.Lock()
+		defer tofuTrustStore.mu.Unlock()
+
+		if trusted, ok := tofuTrustStore.keys[host]; ok {
+			if !bytes.Equal(trusted.Marshal(), key.Marshal()) {
+				return fmt.Errorf("git: host key for %q does not match the key trusted on first use", hostname)
+			}
+			return nil
+		}
+
+		tofuTrustStore.keys[host] = key
+		if w != nil {
+			fmt.Fprintln(w, knownhosts.Line([]string{host}, key))
+		}
+		return nil
+	}
+}
+
+func parsePinnedHostKeys(pinned map[string]string) (map[string]gossh.PublicKey, error) {
+	result := make(map[string]gossh.PublicKey, len(pinned))
+
+	for host, encoded := range pinned {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("git: decoding pinned host key for %q: %w", host, err)
+		}
+
+		key, err := gossh.ParsePublicKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("git: parsing pinned host key for %q: %w", host, err)
+		}
+
+		result[knownhosts.Normalize(host)] = key
+	}
+
+	return result, nil
+}
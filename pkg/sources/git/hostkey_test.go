@@ -0,0 +1,32 @@
+package git
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestTOFUHostKeyCallbackTrustsFirstKeyAndRejectsChangedKey(t *testing.T) {
+	tofuTrustStore.keys = make(map[string]gossh.PublicKey)
+
+	firstPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	firstKey, err := gossh.NewPublicKey(firstPub)
+	require.NoError(t, err)
+
+	secondPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	secondKey, err := gossh.NewPublicKey(secondPub)
+	require.NoError(t, err)
+
+	callback := tofuHostKeyCallback(nil)
+
+	require.NoError(t, callback("example.com:22", nil, firstKey), "first connection should trust the key on first use")
+	assert.NoError(t, callback("example.com:22", nil, firstKey), "the same key on a later connection should still be trusted")
+
+	err = callback("example.com:22", nil, secondKey)
+	assert.Error(t, err, "a changed key for a previously trusted host must be rejected")
+}
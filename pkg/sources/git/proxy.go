@@ -0,0 +1,25 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// BasicAuth carries the credentials sent as a Proxy-Authorization: Basic
+// header during the proxy's CONNECT handshake.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// proxyOptions builds the transport.ProxyOptions go-git expects on
+// CloneOptions and FetchOptions from options.ProxyURL/options.ProxyAuth.
+// go-git honors this natively for both http(s) and ssh remotes: its SSH
+// transport dials through golang.org/x/net/proxy.
+func proxyOptions(options CloneOptions) transport.ProxyOptions {
+	proxy := transport.ProxyOptions{URL: options.ProxyURL}
+	if options.ProxyAuth != nil {
+		proxy.Username = options.ProxyAuth.Username
+		proxy.Password = options.ProxyAuth.Password
+	}
+	return proxy
+}
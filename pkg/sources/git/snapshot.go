@@ -0,0 +1,107 @@
+package git
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Snapshot is a read-only view of a repository's tree at a single revision.
+// It lets callers inspect file contents without materialising the working
+// tree a second time.
+type Snapshot struct {
+	repo *git.Repository
+	tree *object.Tree
+}
+
+// OpenAt opens the repository already cloned at projectRoot and returns a
+// Snapshot of it at revision, which is resolved the same way Clone's
+// Revision option is: a branch, a tag, or a commit SHA.
+func (g Source) OpenAt(projectRoot string, revision string) (*Snapshot, error) {
+	repo, err := git.PlainOpen(filepath.Clean(projectRoot))
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{repo: repo, tree: tree}, nil
+}
+
+// File returns the contents of path at the snapshot's revision.
+func (s *Snapshot) File(path string) ([]byte, error) {
+	file, err := s.tree.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(contents), nil
+}
+
+// Walk calls fn for every file in the snapshot's tree, recursing into
+// directories. It stops and returns the first error fn returns.
+func (s *Snapshot) Walk(fn func(path string, mode fs.FileMode, size int64) error) error {
+	walker := object.NewTreeWalker(s.tree, true, nil)
+	defer walker.Close()
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !entry.Mode.IsFile() {
+			continue
+		}
+
+		mode, err := entry.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+
+		blob, err := s.repo.BlobObject(entry.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(name, mode, blob.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// Blob returns a reader over the raw contents of the blob identified by
+// hash, a 40-character hex object hash.
+func (s *Snapshot) Blob(hash string) (io.ReadCloser, error) {
+	blob, err := s.repo.BlobObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return blob.Reader()
+}
@@ -1,20 +1,109 @@
 package git
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/zablvit/mercator/pkg/sources/git/auth"
 )
 
 type Source struct {
 }
 
 type CloneOptions struct {
-	PemBytes    []byte
-	PemPassword string
+	// Auth authenticates against repoUrl directly, e.g. auth.SSHKey or
+	// auth.HTTPToken. Takes precedence over Credentials.
+	Auth auth.Auth
+
+	// Credentials resolves Auth by the host of repoUrl, letting one Source
+	// pull from several forges with different credentials. Used when Auth
+	// is nil.
+	Credentials *auth.CredentialStore
+
+	// FastForwardOnly makes Sync refuse to move the working tree when the
+	// local branch has diverged from the remote instead of silently
+	// rewriting history.
+	FastForwardOnly bool
+
+	// Revision pins Clone to an exact reference instead of the tip of
+	// branch. It accepts "refs/heads/x", "refs/tags/vX", or a 40-character
+	// hex commit SHA, and is resolved with go-git's ResolveRevision after
+	// the clone completes. When set, Clone leaves the repository in a
+	// detached HEAD state at the resolved commit. Branch stays the default
+	// path when Revision is empty.
+	Revision string
+
+	// HostKeyPolicy controls how an SSH remote's host key is verified.
+	// Defaults to StrictKnownHosts.
+	HostKeyPolicy HostKeyPolicy
+
+	// KnownHosts is a known_hosts file's contents, used by StrictKnownHosts.
+	// Takes precedence over KnownHostsPath.
+	KnownHosts []byte
+
+	// KnownHostsPath is a path to a known_hosts file, used by
+	// StrictKnownHosts when KnownHosts is empty.
+	KnownHostsPath string
+
+	// TOFUKnownHostsWriter receives a known_hosts line for every host key
+	// trusted on first use under the TOFU policy.
+	TOFUKnownHostsWriter io.Writer
+
+	// PinnedHostKeys declares trusted host keys inline (host -> base64 SSH
+	// public key), bypassing HostKeyPolicy for the hosts listed. This lets
+	// callers deploying mercator in immutable containers trust specific
+	// fingerprints without shipping a known_hosts file.
+	PinnedHostKeys map[string]string
+
+	// Depth limits Clone to the given number of commits of history. Zero
+	// (the default) clones the full history.
+	Depth int
+
+	// SingleBranch restricts Clone to fetching only the requested branch
+	// instead of every branch on the remote. When Revision is set, the
+	// branch fetched is the remote's default branch (its HEAD).
+	SingleBranch bool
+
+	// RecurseSubmodules clones submodules along with the repository.
+	RecurseSubmodules bool
+
+	// EnableLFS runs `git-lfs pull` in the freshly cloned working tree so
+	// that Git LFS pointers are smudged into their real file contents.
+	EnableLFS bool
+
+	// ProxyURL routes the remote through an HTTP(S) proxy, e.g.
+	// "http://proxy.internal:3128". go-git honors this for ssh:// remotes
+	// as well as http(s)://.
+	ProxyURL string
+
+	// ProxyAuth authenticates against ProxyURL.
+	ProxyAuth *BasicAuth
+}
+
+// ErrDivergingHistory is returned by Sync when the local branch is not an
+// ancestor of the remote branch it is being synced against, so a
+// fast-forward is not possible.
+type ErrDivergingHistory struct {
+	Branch string
+	Local  plumbing.Hash
+	Remote plumbing.Hash
+}
+
+func (e *ErrDivergingHistory) Error() string {
+	return fmt.Sprintf("diverging history on branch %q: local %s is not an ancestor of remote %s", e.Branch, e.Local, e.Remote)
+}
+
+// IsErrDivergingHistory reports whether err is an *ErrDivergingHistory.
+func IsErrDivergingHistory(err error) bool {
+	_, ok := err.(*ErrDivergingHistory)
+	return ok
 }
 
 func (g Source) Clone(repoUrl string, branch string, projectRoot string, options CloneOptions) error {
@@ -26,29 +115,180 @@ func (g Source) Clone(repoUrl string, branch string, projectRoot string, options
 		}
 	}
 
-	var auth ssh.AuthMethod = nil
+	authMethod, err := resolveAuth(options, repoUrl)
+	if err != nil {
+		return err
+	}
 
-	if len(options.PemBytes) > 0 {
-		var err error
-		auth, err = ssh.NewPublicKeys("git", options.PemBytes, options.PemPassword)
-		if err != nil {
+	cloneOptions := &git.CloneOptions{
+		URL:               repoUrl,
+		Progress:          os.Stdout,
+		Auth:              authMethod,
+		Depth:             options.Depth,
+		SingleBranch:      options.SingleBranch,
+		RecurseSubmodules: submoduleRecursivity(options.RecurseSubmodules),
+		ProxyOptions:      proxyOptions(options),
+	}
+	if options.Revision == "" {
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(branch)
+	}
+
+	repo, err := git.PlainClone(projectRoot, false, cloneOptions)
+	if err != nil {
+		return err
+	}
+
+	if options.Revision != "" {
+		if err := checkoutRevision(repo, options.Revision); err != nil {
 			return err
 		}
 	}
 
-	_, err := git.PlainClone(projectRoot, false, &git.CloneOptions{
-		URL:           repoUrl,
-		Progress:      os.Stdout,
-		ReferenceName: plumbing.NewBranchReferenceName(branch),
-		Auth:          auth,
+	if options.EnableLFS {
+		return pullLFS(projectRoot)
+	}
+
+	return nil
+}
+
+func submoduleRecursivity(recurse bool) git.SubmoduleRescursivity {
+	if recurse {
+		return git.DefaultSubmoduleRecursionDepth
+	}
+	return git.NoRecurseSubmodules
+}
+
+// pullLFS shells out to `git-lfs pull`, smudging Git LFS pointers in an
+// already cloned working tree into their real file contents.
+func pullLFS(projectRoot string) error {
+	cmd := exec.Command("git-lfs", "pull")
+	cmd.Dir = projectRoot
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func checkoutRevision(repo *git.Repository, revision string) error {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{Hash: *hash})
+}
+
+// Sync brings an already cloned repository up to date with its remote
+// instead of requiring callers to discard and re-clone it. It fetches the
+// given branch and fast-forwards the working tree to its tip. If the local
+// branch has diverged from the remote and options.FastForwardOnly is set,
+// Sync returns an *ErrDivergingHistory instead of rewriting history.
+func (g Source) Sync(repoUrl string, branch string, projectRoot string, options CloneOptions) error {
+	projectRootCleanPath := filepath.Clean(projectRoot)
+
+	repo, err := git.PlainOpen(projectRootCleanPath)
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	authMethod, err := resolveAuth(options, repoUrl)
+	if err != nil {
+		return err
+	}
+
+	branchRefName := plumbing.NewBranchReferenceName(branch)
+
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName:   "origin",
+		Auth:         authMethod,
+		Progress:     os.Stdout,
+		ProxyOptions: proxyOptions(options),
 	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	remoteRef, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
 	if err != nil {
 		return err
 	}
 
+	headRef, err := repo.Head()
+	if err != nil {
+		return err
+	}
+
+	if headRef.Hash() != remoteRef.Hash() {
+		localCommit, err := repo.CommitObject(headRef.Hash())
+		if err != nil {
+			return err
+		}
+
+		remoteCommit, err := repo.CommitObject(remoteRef.Hash())
+		if err != nil {
+			return err
+		}
+
+		isAncestor, err := localCommit.IsAncestor(remoteCommit)
+		if err != nil {
+			return err
+		}
+
+		if !isAncestor && options.FastForwardOnly {
+			return &ErrDivergingHistory{Branch: branch, Local: headRef.Hash(), Remote: remoteRef.Hash()}
+		}
+
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRefName, remoteRef.Hash())); err != nil {
+			return err
+		}
+
+		if err := worktree.Checkout(&git.CheckoutOptions{Branch: branchRefName, Force: true}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// resolveAuth picks the transport.AuthMethod for repoUrl: an explicit
+// options.Auth wins, otherwise options.Credentials is asked to resolve one
+// by host, otherwise the remote is accessed anonymously.
+func resolveAuth(options CloneOptions, repoUrl string) (transport.AuthMethod, error) {
+	provider := options.Auth
+
+	if provider == nil && options.Credentials != nil {
+		var err error
+		provider, err = options.Credentials.Resolve(repoUrl)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if provider == nil {
+		return nil, nil
+	}
+
+	method, err := provider.Method(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyHostKeyPolicy(method, options); err != nil {
+		return nil, err
+	}
+
+	return method, nil
+}
+
 func New() Source {
 	return Source{}
 }